@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+var logFormat = flag.String("log-format", "text", "Access log format: text or json")
+
+var accessLogger *slog.Logger
+
+// initAccessLogger builds the slog.Logger used for structured access logs,
+// based on -log-format. Plain operational logging elsewhere still goes
+// through the standard "log" package.
+func initAccessLogger() {
+	var handler slog.Handler
+	if *logFormat == "json" {
+		handler = slog.NewJSONHandler(os.Stderr, nil)
+	} else {
+		handler = slog.NewTextHandler(os.Stderr, nil)
+	}
+	accessLogger = slog.New(handler)
+}
+
+// statusRecordingWriter captures the status code written by the handler so
+// it can be included in the access log and Prometheus labels.
+type statusRecordingWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecordingWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap lets http.ResponseController (used by httputil.ReverseProxy to
+// flush streamed responses like /events) see through to the underlying
+// ResponseWriter's Flusher instead of silently no-opping on this wrapper.
+func (w *statusRecordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// logAccess records one proxied request: client address, TLS client CN (if
+// any), path, the auth decision, the response status, and upstream latency.
+func logAccess(r *http.Request, decision string, status int, latency time.Duration) {
+	var clientCN string
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		clientCN = r.TLS.PeerCertificates[0].Subject.CommonName
+	}
+
+	accessLogger.Info("request",
+		"client", r.RemoteAddr,
+		"tls_cn", clientCN,
+		"method", r.Method,
+		"path", r.URL.Path,
+		"decision", decision,
+		"status", status,
+		"latency_ms", latency.Milliseconds(),
+	)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+var redactLabelPrefixes = flag.String("redact-label-prefix", "com.docker.compose.project.working_dir", "Comma separated label key prefixes to redact from container responses")
+
+// redactedLabelPrefixes parses -redact-label-prefix into a slice, trimming
+// whitespace and dropping empty entries.
+func redactedLabelPrefixes() []string {
+	var prefixes []string
+	for _, p := range strings.Split(*redactLabelPrefixes, ",") {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			prefixes = append(prefixes, p)
+		}
+	}
+	return prefixes
+}
+
+// filterContainerListResponse streams the /containers/json array
+// element-by-element instead of buffering the whole response, redacting
+// each container the same way filterContainerFields does.
+func filterContainerListResponse(resp *http.Response) error {
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	return streamFilterArray(resp, filterContainerFields)
+}
+
+// filterInspectResponse streams a single /containers/{id}/json object,
+// applying the same redactions as the list endpoint plus stripping
+// Config.Env.
+func filterInspectResponse(resp *http.Response) error {
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	return streamFilterObject(resp, func(item map[string]interface{}) {
+		filterContainerFields(item)
+		if config, ok := item["Config"].(map[string]interface{}); ok {
+			config["Env"] = []string{}
+		}
+	})
+}
+
+// filterContainerFields applies the redactions shared by both the
+// container-list and container-inspect shapes: label prefixes, mount
+// sources, and per-network IPAM config.
+func filterContainerFields(item map[string]interface{}) {
+	redactLabels(item, "Labels")
+	if config, ok := item["Config"].(map[string]interface{}); ok {
+		redactLabels(config, "Labels")
+	}
+	scrubMountSources(item)
+	dropNetworkIPAMConfig(item)
+}
+
+func redactLabels(obj map[string]interface{}, key string) {
+	labels, ok := obj[key].(map[string]interface{})
+	if !ok {
+		return
+	}
+	prefixes := redactedLabelPrefixes()
+	for k := range labels {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(k, prefix) {
+				labels[k] = "REDACTED"
+				break
+			}
+		}
+	}
+}
+
+func scrubMountSources(item map[string]interface{}) {
+	mounts, ok := item["Mounts"].([]interface{})
+	if !ok {
+		return
+	}
+	for _, m := range mounts {
+		mount, ok := m.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, ok := mount["Source"]; ok {
+			mount["Source"] = "REDACTED"
+		}
+	}
+}
+
+func dropNetworkIPAMConfig(item map[string]interface{}) {
+	settings, ok := item["NetworkSettings"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	networks, ok := settings["Networks"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	for _, n := range networks {
+		network, ok := n.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		delete(network, "IPAMConfig")
+	}
+}
+
+// streamFilterArray decodes resp.Body as a JSON array, applies transform to
+// each element as it's decoded, and re-encodes the (possibly huge) array
+// through an io.Pipe without ever holding the full response in memory.
+func streamFilterArray(resp *http.Response, transform func(map[string]interface{})) error {
+	pr, pw := io.Pipe()
+
+	go func() {
+		defer resp.Body.Close()
+		defer pw.Close()
+
+		dec := json.NewDecoder(resp.Body)
+		if _, err := dec.Token(); err != nil { // consume opening '['
+			pw.CloseWithError(err)
+			return
+		}
+
+		if _, err := pw.Write([]byte{'['}); err != nil {
+			return
+		}
+
+		first := true
+		for dec.More() {
+			var item map[string]interface{}
+			if err := dec.Decode(&item); err != nil {
+				log.Printf("streamFilterArray: decode error: %v", err)
+				pw.CloseWithError(err)
+				return
+			}
+			transform(item)
+
+			if !first {
+				if _, err := pw.Write([]byte{','}); err != nil {
+					return
+				}
+			}
+			first = false
+
+			b, err := json.Marshal(item)
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if _, err := pw.Write(b); err != nil {
+				return
+			}
+		}
+
+		pw.Write([]byte{']'})
+	}()
+
+	resp.Body = pr
+	resp.ContentLength = -1
+	resp.Header.Del("Content-Length")
+	return nil
+}
+
+// streamFilterObject decodes resp.Body as a single JSON object, applies
+// transform, and re-encodes it through an io.Pipe.
+func streamFilterObject(resp *http.Response, transform func(map[string]interface{})) error {
+	pr, pw := io.Pipe()
+
+	go func() {
+		defer resp.Body.Close()
+		defer pw.Close()
+
+		var item map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&item); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		transform(item)
+
+		b, err := json.Marshal(item)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		pw.Write(b)
+	}()
+
+	resp.Body = pr
+	resp.ContentLength = -1
+	resp.Header.Del("Content-Length")
+	return nil
+}
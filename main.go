@@ -12,7 +12,6 @@ import (
 	"net/http/httputil"
 	"os"
 	"os/signal"
-	"regexp"
 	"strconv"
 	"strings"
 	"syscall"
@@ -20,11 +19,14 @@ import (
 )
 
 var (
-	addr           = flag.String("addr", "", "Addresses to listen on (comma separated). Default: 127.0.0.1:2375 (or DSS_ADDR env)")
+	addr           = flag.String("addr", "", "Addresses to listen on (comma separated). Prefix an entry with tls:// to serve it over TLS. Default: 127.0.0.1:2375 (or DSS_ADDR env)")
 	socketPath     = flag.String("socket", "", "Path to Docker socket. Default: /var/run/docker.sock (or DSS_SOCKET env)")
 	safeSocketPath = flag.String("safe-socket", "", "Path to create safe Unix socket. (or DSS_SAFE_SOCKET env)")
-	// Allowed paths regex
-	allowedPaths = regexp.MustCompile(`^/(v[\d\.]+/)??(version|_ping|events|containers/json|containers/[a-zA-Z0-9_.-]+/json)$`)
+	policyFile     = flag.String("policy-file", "", "Path to a YAML or JSON policy file of method/path rules. Overrides -policy")
+	policyName     = flag.String("policy", "default", "Built-in policy preset to use when -policy-file isn't set: default, traefik, prometheus-cadvisor-like, portainer-readonly")
+
+	// activePolicy is resolved once in parseConfig and used by authMiddleware.
+	activePolicy *Policy
 )
 
 func main() {
@@ -51,6 +53,15 @@ func main() {
 	handler := authMiddleware(proxy)
 	var servers []*http.Server
 
+	if s := startMetricsServer(); s != nil {
+		servers = append(servers, s)
+	}
+
+	// 0. systemd socket activation (LISTEN_FDS). Sockets handed to us this
+	// way are already opened with whatever SocketMode=/SocketUser=/ACLs the
+	// unit file configured, so we don't chmod or unlink them ourselves.
+	servers = append(servers, systemdServers(handler)...)
+
 	// 1. TCP Listeners
 	if *addr != "" {
 		addrs := strings.Split(*addr, ",")
@@ -60,18 +71,36 @@ func main() {
 				continue
 			}
 
+			a, isTLS := splitTLSAddr(a)
+
 			srv := &http.Server{
 				Addr:    a,
 				Handler: handler,
 			}
+
+			if isTLS {
+				tlsConfig, err := buildTLSConfig()
+				if err != nil {
+					log.Fatalf("TLS config error for %s: %v", a, err)
+				}
+				srv.TLSConfig = tlsConfig
+			}
+
 			servers = append(servers, srv)
 
-			go func(s *http.Server) {
+			go func(s *http.Server, isTLS bool) {
+				if isTLS {
+					log.Printf("Listening on TLS %s, proxying to %s", s.Addr, *socketPath)
+					if err := s.ListenAndServeTLS("", ""); err != http.ErrServerClosed {
+						log.Fatalf("TLS Server error on %s: %v", s.Addr, err)
+					}
+					return
+				}
 				log.Printf("Listening on TCP %s, proxying to %s", s.Addr, *socketPath)
 				if err := s.ListenAndServe(); err != http.ErrServerClosed {
 					log.Fatalf("TCP Server error on %s: %v", s.Addr, err)
 				}
-			}(srv)
+			}(srv, isTLS)
 		}
 	}
 
@@ -89,13 +118,19 @@ func main() {
 			log.Fatalf("Failed to listen on unix socket %s: %v", *safeSocketPath, err)
 		}
 
-		// Set permissions to 0666 so anyone can write (it's safe-sock after all)
-		if err := os.Chmod(*safeSocketPath, 0666); err != nil {
+		if err := os.Chmod(*safeSocketPath, os.FileMode(*safeSocketMode)); err != nil {
 			log.Printf("Warning: Failed to chmod %s: %v", *safeSocketPath, err)
 		}
 
+		// Wrap the listener so every connection's peer uid/gid/pid is known
+		// up front; authMiddleware enforces -safe-socket-uid-allow /
+		// -safe-socket-gid-allow against it instead of relying solely on
+		// the file mode above.
+		pl := newPeerCredListener(l.(*net.UnixListener))
+
 		srv := &http.Server{
-			Handler: handler,
+			Handler:     handler,
+			ConnContext: connContext,
 		}
 		servers = append(servers, srv)
 
@@ -104,7 +139,7 @@ func main() {
 			if err := s.Serve(l); err != http.ErrServerClosed {
 				log.Fatalf("Unix Server error on %s: %v", *safeSocketPath, err)
 			}
-		}(srv, l)
+		}(srv, pl)
 	}
 
 	if len(servers) == 0 {
@@ -178,6 +213,14 @@ func parseConfig() {
 			*socketPath = "/var/run/docker.sock"
 		}
 	}
+
+	policy, err := loadPolicy(*policyFile, *policyName)
+	if err != nil {
+		log.Fatalf("Policy error: %v", err)
+	}
+	activePolicy = policy
+
+	initAccessLogger()
 }
 
 // getDockerBridgeAddrs finds IPs of interfaces starting with 'docker' or 'br-'
@@ -210,34 +253,86 @@ func getDockerBridgeAddrs() []string {
 
 func authMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// 1. Only allow GET
-		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		start := time.Now()
+		sw := &statusRecordingWriter{ResponseWriter: w, status: http.StatusOK}
+
+		// 1. Method/path/query rules from the active policy
+		rule := activePolicy.Evaluate(r)
+		if rule == nil {
+			http.Error(sw, "Forbidden", http.StatusForbidden)
+			finishRequest(r, sw, "blocked_policy", start)
 			return
 		}
+		r = r.WithContext(context.WithValue(r.Context(), ruleContextKey, rule))
+
+		// 2. TLS client cert CN/SAN allowlist, for connections on a tls://
+		// listener. Non-TLS listeners (-addr, -safe-socket) aren't subject
+		// to this check.
+		if allow := allowedClientCNs(); allow != nil && r.TLS != nil {
+			if !clientCertAllowed(allow, r.TLS.PeerCertificates) {
+				http.Error(sw, "Forbidden", http.StatusForbidden)
+				finishRequest(r, sw, "blocked_tls_cn", start)
+				return
+			}
+		}
 
-		// 2. Allowlist paths
-		if !allowedPaths.MatchString(r.URL.Path) {
-			log.Printf("Blocked path: %s", r.URL.Path)
-			http.Error(w, "Forbidden", http.StatusForbidden)
-			return
+		// 3. Unix peer credentials, for connections on -safe-socket
+		if cred := peerCredFromContext(r.Context()); cred != nil {
+			if !peerCredAllowed(cred) {
+				log.Printf("Blocked safe-socket peer uid=%d gid=%d pid=%d", cred.UID, cred.GID, cred.PID)
+				http.Error(sw, "Forbidden", http.StatusForbidden)
+				finishRequest(r, sw, "blocked_peer_cred", start)
+				return
+			}
 		}
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(sw, r)
+		finishRequest(r, sw, "allowed", start)
 	})
 }
 
+// finishRequest records the structured access log entry and Prometheus
+// metrics once a request has been fully handled.
+func finishRequest(r *http.Request, sw *statusRecordingWriter, decision string, start time.Time) {
+	latency := time.Since(start)
+	status := strconv.Itoa(sw.status)
+
+	logAccess(r, decision, sw.status, latency)
+	requestsTotal.WithLabelValues(r.URL.Path, r.Method, status).Inc()
+	upstreamLatency.WithLabelValues(r.URL.Path).Observe(latency.Seconds())
+}
+
+// Names a policy Rule's ResponseFilter can take. A custom -policy-file rule
+// that matches a request outside the built-in path shapes below can set one
+// of these to tell modifyResponse which filter to apply.
+const (
+	responseFilterContainersList   = "containers-list"
+	responseFilterContainerInspect = "container-inspect"
+	responseFilterEvents           = "events"
+)
+
 func modifyResponse(resp *http.Response) error {
 	path := resp.Request.URL.Path
 
+	// A matched rule naming an explicit ResponseFilter takes precedence over
+	// the path-shape guesses below, so custom -policy-file rules can route
+	// non-standard paths through one of the known filters.
+	if rule := ruleFromContext(resp.Request.Context()); rule != nil && rule.ResponseFilter != "" {
+		switch rule.ResponseFilter {
+		case responseFilterContainersList:
+			return filterContainerListResponse(resp)
+		case responseFilterContainerInspect:
+			return filterInspectResponse(resp)
+		case responseFilterEvents:
+			return filterEventsResponse(resp)
+		default:
+			log.Printf("modifyResponse: rule has unknown responseFilter %q, falling back to path matching", rule.ResponseFilter)
+		}
+	}
+
 	// Handle Container List: /containers/json
 	if strings.Contains(path, "/containers/json") {
-		// Traefik uses list to get basic info. Usually Env is NOT in list response unless size=true?
-		// Actually, 'docker ps' doesn't show envs. Inspection does.
-		// But let's be safe. If the response is a list of containers, we might want to ensure no sensitive data if it was there.
-		// The standard /containers/json response does NOT contain Env.
-		// It contains specific fields.
-		return nil
+		return filterContainerListResponse(resp)
 	}
 
 	// Handle Container Inspect: /containers/{id}/json
@@ -247,58 +342,38 @@ func modifyResponse(resp *http.Response) error {
 
 	// Handle Events: /events
 	if strings.Contains(path, "/events") {
-		// Create a pipe to filter the stream
-		pr, pw := io.Pipe()
-
-		go func() {
-			defer resp.Body.Close()
-			defer pw.Close()
-
-			scanner := bufio.NewScanner(resp.Body)
-			for scanner.Scan() {
-				line := scanner.Bytes()
-				if shouldKeepEvent(line) {
-					if _, err := pw.Write(append(line, '\n')); err != nil {
-						return // Downstream closed
-					}
-				}
-			}
-		}()
-
-		resp.Body = pr
-		return nil
+		return filterEventsResponse(resp)
 	}
 
 	return nil
 }
 
-// filterInspectResponse strips Env variables from container inspection
-func filterInspectResponse(resp *http.Response) error {
-	if resp.StatusCode != http.StatusOK {
-		return nil
-	}
-
-	var data map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return err
-	}
-	resp.Body.Close()
-
-	// Strip Config.Env
-	if config, ok := data["Config"].(map[string]interface{}); ok {
-		config["Env"] = []string{} // Clear Envs
-	}
-
-	// Strip ContainerJSONBase.HostConfig.Env (if present in some versions?) usually it's in Config.
-
-	newBody, err := json.Marshal(data)
-	if err != nil {
-		return err
-	}
+// filterEventsResponse streams /events, dropping any event that
+// shouldKeepEvent rejects instead of buffering the whole (unbounded) stream.
+func filterEventsResponse(resp *http.Response) error {
+	pr, pw := io.Pipe()
+
+	openEventStreams.Inc()
+
+	go func() {
+		defer resp.Body.Close()
+		defer pw.Close()
+		defer openEventStreams.Dec()
+
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Bytes()
+			keep := shouldKeepEvent(line)
+			recordEventMetric(line, keep)
+			if keep {
+				if _, err := pw.Write(append(line, '\n')); err != nil {
+					return // Downstream closed
+				}
+			}
+		}
+	}()
 
-	resp.Body = io.NopCloser(strings.NewReader(string(newBody)))
-	resp.ContentLength = int64(len(newBody))
-	resp.Header.Set("Content-Length", strconv.Itoa(len(newBody)))
+	resp.Body = pr
 	return nil
 }
 
@@ -336,3 +411,21 @@ func shouldKeepEvent(line []byte) bool {
 	// log.Printf("Dropping event: type=%s action=%s", event.Type, event.Action)
 	return false
 }
+
+// recordEventMetric updates the events_total counter for one raw event
+// line, labeled by its type/action and whether it was forwarded or dropped.
+func recordEventMetric(line []byte, kept bool) {
+	var event struct {
+		Type   string `json:"type"`
+		Action string `json:"action"`
+	}
+	if err := json.Unmarshal(line, &event); err != nil {
+		return
+	}
+
+	decision := "dropped"
+	if kept {
+		decision = "forwarded"
+	}
+	eventsTotal.WithLabelValues(event.Type, event.Action, decision).Inc()
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var metricsAddr = flag.String("metrics-addr", "", "Address for an internal Prometheus /metrics listener, e.g. 127.0.0.1:9090 (disabled if empty)")
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "safesock_requests_total",
+		Help: "Requests served by safe-sock, by path, method and response status.",
+	}, []string{"path", "method", "status"})
+
+	upstreamLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "safesock_upstream_latency_seconds",
+		Help:    "Latency of proxied requests to the Docker socket.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"path"})
+
+	eventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "safesock_events_total",
+		Help: "Docker events observed on /events, by type, action and whether they were forwarded or dropped.",
+	}, []string{"type", "action", "decision"})
+
+	openEventStreams = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "safesock_open_event_streams",
+		Help: "Number of currently open /events streams being proxied.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(requestsTotal, upstreamLatency, eventsTotal, openEventStreams)
+}
+
+// startMetricsServer launches the internal Prometheus listener, if
+// -metrics-addr is set. It's deliberately not wrapped in authMiddleware:
+// it's meant to be bound to a private address, not exposed alongside the
+// Docker proxy.
+func startMetricsServer() *http.Server {
+	if *metricsAddr == "" {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	srv := &http.Server{Addr: *metricsAddr, Handler: mux}
+
+	go func() {
+		log.Printf("Listening on %s for Prometheus metrics", srv.Addr)
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			log.Fatalf("Metrics server error on %s: %v", srv.Addr, err)
+		}
+	}()
+
+	return srv
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+)
+
+var (
+	safeSocketUIDAllow = flag.String("safe-socket-uid-allow", "", "Comma separated list of UIDs allowed to connect to -safe-socket (empty = no UID restriction). If -safe-socket-gid-allow is also set, a peer must satisfy both lists.")
+	safeSocketGIDAllow = flag.String("safe-socket-gid-allow", "", "Comma separated list of GIDs allowed to connect to -safe-socket (empty = no GID restriction). If -safe-socket-uid-allow is also set, a peer must satisfy both lists.")
+	safeSocketMode     = flag.Uint("safe-socket-mode", 0660, "File mode to chmod -safe-socket to")
+)
+
+// PeerCred is the credentials of the process on the other end of a
+// -safe-socket connection, read from the kernel at accept time.
+type PeerCred struct {
+	UID uint32
+	GID uint32
+	PID int32
+}
+
+type peerCredContextKeyType struct{}
+
+var peerCredContextKey peerCredContextKeyType
+
+// peerCredListener wraps a Unix net.Listener so every accepted connection
+// has its peer credentials (SO_PEERCRED on Linux, LOCAL_PEERCRED on BSD)
+// looked up once, up front, via getPeerCred.
+type peerCredListener struct {
+	*net.UnixListener
+}
+
+func newPeerCredListener(l *net.UnixListener) *peerCredListener {
+	return &peerCredListener{UnixListener: l}
+}
+
+func (pl *peerCredListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := pl.AcceptUnix()
+		if err != nil {
+			return nil, err
+		}
+
+		cred, err := getPeerCred(conn)
+		if err != nil {
+			log.Printf("Failed to read peer credentials, closing connection: %v", err)
+			conn.Close()
+			continue
+		}
+
+		return &peerCredConn{UnixConn: conn, cred: cred}, nil
+	}
+}
+
+type peerCredConn struct {
+	*net.UnixConn
+	cred *PeerCred
+}
+
+// connContext is installed as http.Server.ConnContext on the safe-socket
+// server so authMiddleware can read the peer credentials of the connection
+// a request arrived on back out of its context.
+func connContext(ctx context.Context, c net.Conn) context.Context {
+	if pc, ok := c.(*peerCredConn); ok {
+		return context.WithValue(ctx, peerCredContextKey, pc.cred)
+	}
+	return ctx
+}
+
+// peerCredFromContext returns the PeerCred stashed by connContext, or nil
+// if the request didn't arrive over a peer-cred-checked connection.
+func peerCredFromContext(ctx context.Context) *PeerCred {
+	cred, _ := ctx.Value(peerCredContextKey).(*PeerCred)
+	return cred
+}
+
+func parseIDAllowList(csv string) map[uint32]bool {
+	if csv == "" {
+		return nil
+	}
+	allow := make(map[uint32]bool)
+	for _, s := range strings.Split(csv, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			log.Printf("Ignoring invalid id %q in allow list", s)
+			continue
+		}
+		allow[uint32(id)] = true
+	}
+	return allow
+}
+
+// peerCredAllowed checks cred's UID/GID against -safe-socket-uid-allow /
+// -safe-socket-gid-allow. Neither flag set means no restriction. When both
+// are set, cred must satisfy both lists (an AND), not either one, so that
+// combining them always tightens rather than loosens the restriction.
+func peerCredAllowed(cred *PeerCred) bool {
+	uidAllow := parseIDAllowList(*safeSocketUIDAllow)
+	gidAllow := parseIDAllowList(*safeSocketGIDAllow)
+	if uidAllow == nil && gidAllow == nil {
+		return true
+	}
+	if cred == nil {
+		return false
+	}
+	if uidAllow != nil && !uidAllow[cred.UID] {
+		return false
+	}
+	if gidAllow != nil && !gidAllow[cred.GID] {
+		return false
+	}
+	return true
+}
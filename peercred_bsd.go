@@ -0,0 +1,37 @@
+//go:build darwin || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// getPeerCred reads the connecting process's uid/gid via LOCAL_PEERCRED,
+// the BSD-family equivalent of Linux's SO_PEERCRED. BSD's xucred doesn't
+// carry a pid, so PID is left unset (-1).
+func getPeerCred(conn *net.UnixConn) (*PeerCred, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var cred *unix.Xucred
+	var sockErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, sockErr = unix.GetsockoptXucred(int(fd), unix.SOL_LOCAL, unix.LOCAL_PEERCRED)
+	}); err != nil {
+		return nil, err
+	}
+	if sockErr != nil {
+		return nil, sockErr
+	}
+
+	gid := uint32(0)
+	if len(cred.Groups) > 0 {
+		gid = uint32(cred.Groups[0])
+	}
+
+	return &PeerCred{UID: cred.Uid, GID: gid, PID: -1}, nil
+}
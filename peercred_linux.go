@@ -0,0 +1,31 @@
+//go:build linux
+
+package main
+
+import (
+	"net"
+	"syscall"
+)
+
+// getPeerCred reads the connecting process's uid/gid/pid via SO_PEERCRED,
+// the standard mechanism snapd and others use to authorize peers on a Unix
+// socket.
+func getPeerCred(conn *net.UnixConn) (*PeerCred, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var cred *syscall.Ucred
+	var sockErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, sockErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return nil, err
+	}
+	if sockErr != nil {
+		return nil, sockErr
+	}
+
+	return &PeerCred{UID: cred.Uid, GID: cred.Gid, PID: cred.Pid}, nil
+}
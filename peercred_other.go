@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+func getPeerCred(conn *net.UnixConn) (*PeerCred, error) {
+	return nil, errors.New("peer credential lookup is not supported on this platform")
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"testing"
+)
+
+func withAllowFlags(t *testing.T, uidAllow, gidAllow string) {
+	t.Helper()
+	prevUID, prevGID := *safeSocketUIDAllow, *safeSocketGIDAllow
+	flag.Set("safe-socket-uid-allow", uidAllow)
+	flag.Set("safe-socket-gid-allow", gidAllow)
+	t.Cleanup(func() {
+		flag.Set("safe-socket-uid-allow", prevUID)
+		flag.Set("safe-socket-gid-allow", prevGID)
+	})
+}
+
+func TestPeerCredAllowedConjunctiveUIDAndGID(t *testing.T) {
+	withAllowFlags(t, "1000", "2000")
+
+	if peerCredAllowed(&PeerCred{UID: 1000, GID: 9999}) {
+		t.Error("matching only the UID list should not be allowed when GID list is also set")
+	}
+	if peerCredAllowed(&PeerCred{UID: 9999, GID: 2000}) {
+		t.Error("matching only the GID list should not be allowed when UID list is also set")
+	}
+	if !peerCredAllowed(&PeerCred{UID: 1000, GID: 2000}) {
+		t.Error("matching both lists should be allowed")
+	}
+}
+
+func TestPeerCredAllowedNoRestriction(t *testing.T) {
+	withAllowFlags(t, "", "")
+
+	if !peerCredAllowed(&PeerCred{UID: 1234, GID: 5678}) {
+		t.Error("no allow lists configured should mean no restriction")
+	}
+	if !peerCredAllowed(nil) {
+		t.Error("no allow lists configured should allow a nil cred too")
+	}
+}
+
+func TestPeerCredAllowedSingleList(t *testing.T) {
+	withAllowFlags(t, "1000", "")
+
+	if !peerCredAllowed(&PeerCred{UID: 1000, GID: 42}) {
+		t.Error("UID in the allow list should be allowed regardless of GID")
+	}
+	if peerCredAllowed(&PeerCred{UID: 1, GID: 42}) {
+		t.Error("UID not in the allow list should be blocked")
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule describes one allowed route: which methods, which path pattern, and
+// any query parameters that are forbidden even on an otherwise-matching
+// request (e.g. "don't let /containers/json?all=1 through").
+type Rule struct {
+	Methods     []string          `json:"methods" yaml:"methods"`
+	Path        string            `json:"path" yaml:"path"`
+	PathIsRegex bool              `json:"pathIsRegex" yaml:"pathIsRegex"`
+	ForbidQuery map[string]string `json:"forbidQuery" yaml:"forbidQuery"` // param -> forbidden value, "*" means any non-empty value
+	// ResponseFilter optionally names the response filter modifyResponse
+	// should apply for requests matching this rule: "containers-list",
+	// "container-inspect", or "events". Leave empty to fall back to
+	// modifyResponse's built-in path-shape matching.
+	ResponseFilter string `json:"responseFilter" yaml:"responseFilter"`
+
+	compiledRegex *regexp.Regexp
+}
+
+// Policy is an ordered set of Rules. A request is allowed if it matches at
+// least one Rule and doesn't trip that Rule's ForbidQuery constraints.
+type Policy struct {
+	Rules []Rule `json:"rules" yaml:"rules"`
+}
+
+// compile precompiles any regex path patterns so Evaluate doesn't re-parse
+// them per request.
+func (p *Policy) compile() error {
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if r.PathIsRegex {
+			re, err := regexp.Compile(r.Path)
+			if err != nil {
+				return fmt.Errorf("rule %d: invalid path regex %q: %w", i, r.Path, err)
+			}
+			r.compiledRegex = re
+		}
+	}
+	return nil
+}
+
+func (r *Rule) matchesMethod(method string) bool {
+	if len(r.Methods) == 0 {
+		return method == http.MethodGet
+	}
+	for _, m := range r.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesPath(p string) bool {
+	if r.PathIsRegex {
+		return r.compiledRegex.MatchString(p)
+	}
+	ok, err := path.Match(r.Path, p)
+	return err == nil && ok
+}
+
+func (r *Rule) forbidsQuery(req *http.Request) bool {
+	if len(r.ForbidQuery) == 0 {
+		return false
+	}
+	q := req.URL.Query()
+	for param, forbidden := range r.ForbidQuery {
+		val := q.Get(param)
+		if val == "" {
+			continue
+		}
+		if forbidden == "*" || val == forbidden {
+			return true
+		}
+	}
+	return false
+}
+
+type ruleContextKeyType struct{}
+
+var ruleContextKey ruleContextKeyType
+
+// ruleFromContext returns the Rule authMiddleware matched the request
+// against, or nil if it wasn't stashed (e.g. the request was blocked before
+// a rule matched).
+func ruleFromContext(ctx context.Context) *Rule {
+	rule, _ := ctx.Value(ruleContextKey).(*Rule)
+	return rule
+}
+
+// Evaluate returns the first Rule that allows req, or nil if none does.
+func (p *Policy) Evaluate(req *http.Request) *Rule {
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if r.matchesMethod(req.Method) && r.matchesPath(req.URL.Path) && !r.forbidsQuery(req) {
+			return r
+		}
+	}
+	return nil
+}
+
+// defaultPolicy reproduces safe-sock's original hardcoded behavior:
+// GET-only access to version/ping/events/container listing/inspection.
+func defaultPolicy() *Policy {
+	return &Policy{Rules: []Rule{{
+		Methods:     []string{http.MethodGet},
+		Path:        `^/(v[\d\.]+/)??(version|_ping|events|containers/json|containers/[a-zA-Z0-9_.-]+/json)$`,
+		PathIsRegex: true,
+	}}}
+}
+
+// traefikPolicy covers what Traefik's Docker provider polls for in
+// addition to the default rule set.
+func traefikPolicy() *Policy {
+	p := defaultPolicy()
+	p.Rules = append(p.Rules,
+		Rule{Methods: []string{http.MethodGet}, Path: `^/(v[\d\.]+/)??networks$`, PathIsRegex: true},
+		Rule{Methods: []string{http.MethodGet}, Path: `^/(v[\d\.]+/)??info$`, PathIsRegex: true},
+	)
+	return p
+}
+
+// prometheusCadvisorLikePolicy covers the read path a cAdvisor-style
+// container metrics collector needs.
+func prometheusCadvisorLikePolicy() *Policy {
+	p := defaultPolicy()
+	p.Rules = append(p.Rules,
+		Rule{Methods: []string{http.MethodGet}, Path: `^/(v[\d\.]+/)??info$`, PathIsRegex: true},
+		Rule{Methods: []string{http.MethodGet}, Path: `^/(v[\d\.]+/)??containers/[a-zA-Z0-9_.-]+/stats$`, PathIsRegex: true, ForbidQuery: map[string]string{"stream": "1"}},
+	)
+	return p
+}
+
+// portainerReadonlyPolicy is a broader, still read-only, view suitable for
+// Portainer's "read only" agent mode.
+func portainerReadonlyPolicy() *Policy {
+	p := defaultPolicy()
+	p.Rules = append(p.Rules,
+		Rule{Methods: []string{http.MethodGet}, Path: `^/(v[\d\.]+/)??images/json$`, PathIsRegex: true},
+		Rule{Methods: []string{http.MethodGet}, Path: `^/(v[\d\.]+/)??networks$`, PathIsRegex: true},
+		Rule{Methods: []string{http.MethodGet}, Path: `^/(v[\d\.]+/)??volumes$`, PathIsRegex: true},
+		Rule{Methods: []string{http.MethodGet}, Path: `^/(v[\d\.]+/)??nodes$`, PathIsRegex: true},
+		Rule{Methods: []string{http.MethodGet}, Path: `^/(v[\d\.]+/)??info$`, PathIsRegex: true},
+	)
+	return p
+}
+
+// builtinPolicies are selectable via -policy without needing a -policy-file.
+var builtinPolicies = map[string]func() *Policy{
+	"default":                  defaultPolicy,
+	"traefik":                  traefikPolicy,
+	"prometheus-cadvisor-like": prometheusCadvisorLikePolicy,
+	"portainer-readonly":       portainerReadonlyPolicy,
+}
+
+// loadPolicy resolves the active Policy: a -policy-file (YAML or JSON, by
+// extension) takes precedence over -policy's built-in preset name.
+func loadPolicy(policyFile, policyName string) (*Policy, error) {
+	if policyFile != "" {
+		data, err := os.ReadFile(policyFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading -policy-file: %w", err)
+		}
+
+		var p Policy
+		if strings.HasSuffix(policyFile, ".json") {
+			err = json.Unmarshal(data, &p)
+		} else {
+			err = yaml.Unmarshal(data, &p)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing -policy-file %s: %w", policyFile, err)
+		}
+		if err := p.compile(); err != nil {
+			return nil, err
+		}
+		return &p, nil
+	}
+
+	factory, ok := builtinPolicies[policyName]
+	if !ok {
+		return nil, fmt.Errorf("unknown -policy %q (known: default, traefik, prometheus-cadvisor-like, portainer-readonly)", policyName)
+	}
+	p := factory()
+	if err := p.compile(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
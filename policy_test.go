@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPolicyEvaluateForbidQuery(t *testing.T) {
+	p := &Policy{Rules: []Rule{{
+		Methods:     []string{http.MethodGet},
+		Path:        "/containers/json",
+		ForbidQuery: map[string]string{"all": "*"},
+	}}}
+	if err := p.compile(); err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/containers/json?all=1", nil)
+	if rule := p.Evaluate(req); rule != nil {
+		t.Fatalf("expected rule to be blocked by forbidQuery wildcard, got match: %+v", rule)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/containers/json", nil)
+	if rule := p.Evaluate(req); rule == nil {
+		t.Fatal("expected request without the forbidden param to match")
+	}
+}
+
+func TestRuleMatchesPathGlobVsRegex(t *testing.T) {
+	glob := Rule{Path: "/containers/*/json"}
+	if !glob.matchesPath("/containers/abc123/json") {
+		t.Error("glob pattern should match a single path segment")
+	}
+	if glob.matchesPath("/containers/abc/def/json") {
+		t.Error("glob pattern should not match across an extra path segment")
+	}
+
+	regex := Rule{Path: `^/containers/[a-zA-Z0-9_.-]+/json$`, PathIsRegex: true}
+	p := &Policy{Rules: []Rule{regex}}
+	if err := p.compile(); err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	r := &p.Rules[0]
+	if !r.matchesPath("/containers/abc123/json") {
+		t.Error("regex pattern should match a valid container id")
+	}
+	if r.matchesPath("/containers/abc/def/json") {
+		t.Error("regex pattern should not match a path with an extra segment")
+	}
+}
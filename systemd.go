@@ -0,0 +1,40 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/coreos/go-systemd/v22/activation"
+)
+
+// systemdServers builds an http.Server for every listener handed to us by
+// systemd socket activation (LISTEN_FDS/LISTEN_PID/LISTEN_FDNAMES), each
+// wrapped in the same authMiddleware(proxy) chain as our own listeners.
+//
+// Named FDs are purely informational here: whatever systemd calls the
+// socket in its unit file shows up in the log line so operators can tell
+// which activated socket is serving a given address.
+func systemdServers(handler http.Handler) []*http.Server {
+	listeners, err := activation.ListenersWithNames()
+	if err != nil {
+		log.Printf("systemd activation: %v", err)
+		return nil
+	}
+
+	var servers []*http.Server
+	for name, ls := range listeners {
+		for _, l := range ls {
+			srv := &http.Server{Handler: handler}
+			servers = append(servers, srv)
+
+			go func(s *http.Server, l net.Listener, name string) {
+				log.Printf("Listening on systemd-activated socket %q (%s %s)", name, l.Addr().Network(), l.Addr().String())
+				if err := s.Serve(l); err != http.ErrServerClosed {
+					log.Fatalf("systemd-activated server error on %q: %v", name, err)
+				}
+			}(srv, l, name)
+		}
+	}
+	return servers
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var (
+	tlsCert          = flag.String("tls-cert", "", "Path to TLS server certificate (required for tls:// addrs)")
+	tlsKey           = flag.String("tls-key", "", "Path to TLS server private key (required for tls:// addrs)")
+	tlsCA            = flag.String("tls-ca", "", "Path to CA bundle to verify client certs against (enables mTLS)")
+	tlsClientCNAllow = flag.String("tls-client-cn-allow", "", "Comma separated list of client cert CN/SAN values allowed to connect (requires -tls-ca)")
+)
+
+// tlsAddrPrefix marks an -addr entry as wanting a TLS listener, e.g.
+// "tls://0.0.0.0:2376".
+const tlsAddrPrefix = "tls://"
+
+// splitTLSAddr strips a "tls://" prefix from an -addr entry and reports
+// whether it was present.
+func splitTLSAddr(a string) (addr string, isTLS bool) {
+	if strings.HasPrefix(a, tlsAddrPrefix) {
+		return strings.TrimPrefix(a, tlsAddrPrefix), true
+	}
+	return a, false
+}
+
+// buildTLSConfig loads the server cert/key and, if -tls-ca is set,
+// configures mutual TLS by requiring and verifying client certificates
+// against that CA bundle.
+func buildTLSConfig() (*tls.Config, error) {
+	if *tlsCert == "" || *tlsKey == "" {
+		return nil, fmt.Errorf("-tls-cert and -tls-key are required for a tls:// listener")
+	}
+
+	cert, err := tls.LoadX509KeyPair(*tlsCert, *tlsKey)
+	if err != nil {
+		return nil, fmt.Errorf("loading TLS cert/key: %w", err)
+	}
+
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	if *tlsCA != "" {
+		caBytes, err := os.ReadFile(*tlsCA)
+		if err != nil {
+			return nil, fmt.Errorf("reading -tls-ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("no certificates found in -tls-ca %s", *tlsCA)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return cfg, nil
+}
+
+// allowedClientCNs parses -tls-client-cn-allow into a lookup set. An empty
+// flag means "no CN restriction beyond -tls-ca verification".
+func allowedClientCNs() map[string]bool {
+	if *tlsClientCNAllow == "" {
+		return nil
+	}
+	allow := make(map[string]bool)
+	for _, cn := range strings.Split(*tlsClientCNAllow, ",") {
+		cn = strings.TrimSpace(cn)
+		if cn != "" {
+			allow[cn] = true
+		}
+	}
+	return allow
+}
+
+// clientCertAllowed reports whether the peer certificate presented by r
+// matches the -tls-client-cn-allow list, checking both CN and SANs.
+func clientCertAllowed(allow map[string]bool, peerCerts []*x509.Certificate) bool {
+	if allow == nil {
+		return true
+	}
+	if len(peerCerts) == 0 {
+		return false
+	}
+	cert := peerCerts[0]
+	if allow[cert.Subject.CommonName] {
+		return true
+	}
+	for _, name := range cert.DNSNames {
+		if allow[name] {
+			return true
+		}
+	}
+	return false
+}